@@ -0,0 +1,310 @@
+/**********************************************
+ * auth.go
+ * -----------
+ * Authenticates incoming requests against a pluggable backend (PAM, LDAP,
+ * or a static htpasswd file) and caches the result behind a signed session
+ * cookie so that every keystroke doesn't re-run a full auth check.
+ *********************************************/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	log "minilog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/msteinert/pam"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/ldap.v2"
+)
+
+const sessionCookieName = "igorweb_session"
+const sessionTTL = 8 * time.Hour
+
+// Authenticator validates a username/password pair against some backend.
+type Authenticator interface {
+	Authenticate(username, password string) (bool, error)
+}
+
+// PAMAuthenticator validates credentials against the local system's PAM
+// stack, using the "igorweb" service by default.
+type PAMAuthenticator struct {
+	Service string
+}
+
+func (a *PAMAuthenticator) Authenticate(username, password string) (bool, error) {
+	t, err := pam.StartFunc(a.Service, username, func(s pam.Style, msg string) (string, error) {
+		switch s {
+		case pam.PromptEchoOff:
+			return password, nil
+		default:
+			return "", nil
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := t.Authenticate(0); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// LDAPAuthenticator validates credentials by binding to an LDAP server as
+// the user.
+type LDAPAuthenticator struct {
+	Addr   string // host:port
+	BindDN string // template, e.g. "uid=%s,ou=People,dc=example,dc=com"
+}
+
+func (a *LDAPAuthenticator) Authenticate(username, password string) (bool, error) {
+	conn, err := ldap.Dial("tcp", a.Addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	dn := fmt.Sprintf(a.BindDN, ldap.EscapeFilter(username))
+	if err := conn.Bind(dn, password); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// HtpasswdAuthenticator validates credentials against a static htpasswd
+// file containing "user:bcrypt-hash" lines.
+type HtpasswdAuthenticator struct {
+	Path string
+
+	lock  sync.Mutex
+	users map[string]string
+}
+
+func NewHtpasswdAuthenticator(path string) (*HtpasswdAuthenticator, error) {
+	a := &HtpasswdAuthenticator{Path: path}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *HtpasswdAuthenticator) load() error {
+	data, err := ioutil.ReadFile(a.Path)
+	if err != nil {
+		return err
+	}
+
+	users := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		users[fields[0]] = fields[1]
+	}
+
+	a.lock.Lock()
+	a.users = users
+	a.lock.Unlock()
+
+	return nil
+}
+
+func (a *HtpasswdAuthenticator) Authenticate(username, password string) (bool, error) {
+	a.lock.Lock()
+	hash, ok := a.users[username]
+	a.lock.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// session is a successfully authenticated user, cached so that the
+// authenticator doesn't need to be consulted on every request.
+type session struct {
+	Username string
+	Expires  time.Time
+}
+
+// sessionCache maps signed session tokens to the session they represent.
+type sessionCache struct {
+	key []byte // HMAC signing key
+
+	lock     sync.Mutex
+	sessions map[string]session
+}
+
+func newSessionCache() *sessionCache {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal("unable to generate session signing key: %v", err)
+	}
+
+	return &sessionCache{
+		key:      key,
+		sessions: make(map[string]session),
+	}
+}
+
+// sign returns a signed token that can be safely handed to a client as a
+// cookie value.
+func (c *sessionCache) sign(token string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(token))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	return token + "." + sig
+}
+
+// verify checks a cookie value's signature and returns the raw token. The
+// comparison is constant-time so that a forged cookie can't be brute-forced
+// byte-by-byte via response timing.
+func (c *sessionCache) verify(value string) (string, bool) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(c.sign(parts[0])), []byte(value)) {
+		return "", false
+	}
+
+	return parts[0], true
+}
+
+// create starts a new session for username and returns a signed cookie
+// value identifying it.
+func (c *sessionCache) create(username string) string {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	c.lock.Lock()
+	c.sessions[token] = session{Username: username, Expires: time.Now().Add(sessionTTL)}
+	c.lock.Unlock()
+
+	return c.sign(token)
+}
+
+// lookup returns the session for a signed cookie value, if it is valid and
+// unexpired.
+func (c *sessionCache) lookup(value string) (session, bool) {
+	token, ok := c.verify(value)
+	if !ok {
+		return session{}, false
+	}
+
+	c.lock.Lock()
+	s, ok := c.sessions[token]
+	c.lock.Unlock()
+
+	if !ok || time.Now().After(s.Expires) {
+		return session{}, false
+	}
+
+	return s, true
+}
+
+var authenticator Authenticator
+var sessions = newSessionCache()
+
+// newAuthenticator builds an Authenticator from the -auth flag, which takes
+// the form "pam[:service]", "ldap:host:port:bindDNTemplate", or
+// "htpasswd:path".
+func newAuthenticator(spec string) (Authenticator, error) {
+	fields := strings.SplitN(spec, ":", 2)
+
+	switch fields[0] {
+	case "pam":
+		service := "igorweb"
+		if len(fields) == 2 {
+			service = fields[1]
+		}
+		return &PAMAuthenticator{Service: service}, nil
+	case "ldap":
+		if len(fields) != 2 {
+			return nil, errors.New("ldap auth requires -auth ldap:host:port:bindDNTemplate")
+		}
+
+		rest := strings.SplitN(fields[1], ":", 3)
+		if len(rest) != 3 {
+			return nil, errors.New("ldap auth requires -auth ldap:host:port:bindDNTemplate")
+		}
+
+		addr := rest[0] + ":" + rest[1]
+		return &LDAPAuthenticator{Addr: addr, BindDN: rest[2]}, nil
+	case "htpasswd":
+		if len(fields) != 2 {
+			return nil, errors.New("htpasswd auth requires -auth htpasswd:path")
+		}
+
+		return NewHtpasswdAuthenticator(fields[1])
+	default:
+		return nil, fmt.Errorf("unknown auth backend: %v", fields[0])
+	}
+}
+
+// authenticateRequest validates the request's session cookie, falling back
+// to the Authorization header (and creating a new session) if there is no
+// valid cookie yet. It writes a 401 and returns ok=false if authentication
+// fails.
+func authenticateRequest(w http.ResponseWriter, r *http.Request) (username string, ok bool) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if s, valid := sessions.lookup(cookie.Value); valid {
+			return s.Username, true
+		}
+	}
+
+	username, password, basicOK := r.BasicAuth()
+	if !basicOK {
+		w.Header().Set("WWW-Authenticate", `Basic realm="igorweb"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return "", false
+	}
+
+	valid, err := authenticator.Authenticate(username, password)
+	if err != nil {
+		log.Warn("authentication error for %v: %v", username, err)
+	}
+	if !valid {
+		w.Header().Set("WWW-Authenticate", `Basic realm="igorweb"`)
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return "", false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessions.create(username),
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  time.Now().Add(sessionTTL),
+	})
+
+	return username, true
+}
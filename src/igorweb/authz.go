@@ -0,0 +1,234 @@
+/**********************************************
+ * authz.go
+ * -----------
+ * Enforces a per-user/group policy on top of authentication: which igor
+ * subcommands a user may run, and which nodes they may run them against.
+ * A rule may grant an explicit node range (Nodes: "*" for all nodes, or a
+ * comma-separated host list), or omit Nodes to fall back to the default:
+ * for del/extend/power, a user may only target nodes belonging to a
+ * reservation they own.
+ *********************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os/user"
+	"ranges"
+	"strings"
+)
+
+// nodeOwnedCommands are the subcommands whose targets must be checked
+// against the reservations the caller owns, per the request: non-admins
+// may only del/extend/power reservations they own.
+var nodeOwnedCommands = map[string]bool{
+	"del":    true,
+	"extend": true,
+	"power":  true,
+}
+
+// AuthzRule grants the listed users and groups access to the listed
+// commands against the listed nodes. An empty Nodes range means "any node
+// the user already owns a reservation on" rather than "all nodes"; use "*"
+// to explicitly grant all nodes.
+type AuthzRule struct {
+	Users    []string `json:"users"`
+	Groups   []string `json:"groups"`
+	Commands []string `json:"commands"`
+	Nodes    string   `json:"nodes"`
+}
+
+// Authorizer decides whether a user is allowed to run an igor subcommand
+// against a set of nodes.
+type Authorizer struct {
+	rules []AuthzRule
+}
+
+// NewAuthorizer loads an Authorizer's rules from a JSON config file.
+func NewAuthorizer(path string) (*Authorizer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []AuthzRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	return &Authorizer{rules: rules}, nil
+}
+
+// userGroups returns the names of the groups username belongs to on this
+// system.
+func userGroups(username string) []string {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil
+	}
+
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil
+	}
+
+	groups := []string{}
+	for _, gid := range gids {
+		if g, err := user.LookupGroupId(gid); err == nil {
+			groups = append(groups, g.Name)
+		}
+	}
+
+	return groups
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether rule applies to username (directly, or via group
+// membership) and grants access to command.
+func (rule AuthzRule) matches(username string, groups []string, command string) bool {
+	if !contains(rule.Commands, command) && !contains(rule.Commands, "*") {
+		return false
+	}
+
+	if contains(rule.Users, username) || contains(rule.Users, "*") {
+		return true
+	}
+
+	for _, g := range groups {
+		if contains(rule.Groups, g) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allNodes reports whether rule grants access to every node, rather than
+// just the nodes the user owns.
+func (rule AuthzRule) allNodes() bool {
+	return rule.Nodes == "*"
+}
+
+// explicitNodes returns the nodes rule grants access to, parsed via the
+// cluster's node range, or nil if the rule instead relies on the default
+// ownership check (Nodes unset).
+func (rule AuthzRule) explicitNodes() []int {
+	if rule.Nodes == "" || rule.Nodes == "*" {
+		return nil
+	}
+
+	cfg := getConfig()
+	rnge, err := ranges.NewRange(cfg.Prefix, cfg.RangeStart, cfg.RangeEnd)
+	if err != nil {
+		return nil
+	}
+
+	return rnge.RangeToInts(strings.Split(rule.Nodes, ","))
+}
+
+// Allowed reports whether username may run command against nodes, given
+// the reservations that username currently owns. For commands that aren't
+// scoped to a reservation's nodes (e.g. "show" or "sub"), nodes may be
+// empty and only the command grant itself is checked.
+func (a *Authorizer) Allowed(username, command string, nodes []int, owned []int) bool {
+	groups := userGroups(username)
+
+	for _, rule := range a.rules {
+		if !rule.matches(username, groups, command) {
+			continue
+		}
+
+		if rule.allNodes() {
+			return true
+		}
+
+		if explicit := rule.explicitNodes(); len(explicit) > 0 {
+			if len(nodes) == 0 && !nodeOwnedCommands[command] {
+				return true
+			}
+			if len(nodes) > 0 && isSubsetOf(nodes, explicit) {
+				return true
+			}
+			continue
+		}
+
+		if !nodeOwnedCommands[command] {
+			return true
+		}
+
+		if len(nodes) > 0 && isSubsetOf(nodes, owned) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSubsetOf reports whether every element of nodes is present in owned.
+func isSubsetOf(nodes, owned []int) bool {
+	ownedSet := make(map[int]bool, len(owned))
+	for _, n := range owned {
+		ownedSet[n] = true
+	}
+
+	for _, n := range nodes {
+		if !ownedSet[n] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ownedNodes returns the nodes reserved by username, according to the
+// current reservation cache.
+func ownedNodes(username string) []int {
+	nodes := []int{}
+	for _, r := range getReservations() {
+		if r.Owner == username {
+			nodes = append(nodes, r.Nodes...)
+		}
+	}
+
+	return nodes
+}
+
+// reservationArg extracts the target reservation name from a split igor
+// command line, e.g. "igor del -r foo" or "igor power on -r foo". It
+// returns "" if no "-r" flag is present.
+func reservationArg(splitcmd []string) string {
+	for i, tok := range splitcmd {
+		if tok == "-r" && i+1 < len(splitcmd) {
+			return splitcmd[i+1]
+		}
+	}
+
+	return ""
+}
+
+// targetNodes returns the nodes belonging to the reservation that splitcmd
+// targets, according to the current reservation cache, or nil if splitcmd
+// doesn't name a known reservation.
+func targetNodes(splitcmd []string) []int {
+	name := reservationArg(splitcmd)
+	if name == "" {
+		return nil
+	}
+
+	for _, r := range getReservations() {
+		if r.Name == name {
+			return r.Nodes
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+/**********************************************
+ * events.go
+ * -----------
+ * Implements a server-sent events hub that pushes reservation and
+ * power-state deltas to connected browsers, replacing the old model of
+ * every browser re-running "igor show" on its own heartbeat.
+ *********************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	log "minilog"
+	"net/http"
+	"sync"
+)
+
+// event is a single change pushed to subscribers. Kind is one of "added",
+// "removed", "changed", or "power".
+type event struct {
+	Kind string      `json:"kind"`
+	Data interface{} `json:"data"`
+}
+
+// eventHub tracks connected SSE subscribers and broadcasts events to all of
+// them.
+type eventHub struct {
+	lock        sync.Mutex
+	subscribers map[chan event]bool
+}
+
+var hub = &eventHub{
+	subscribers: make(map[chan event]bool),
+}
+
+// subscribe registers a new subscriber channel and returns it.
+func (h *eventHub) subscribe() chan event {
+	ch := make(chan event, 16)
+
+	h.lock.Lock()
+	h.subscribers[ch] = true
+	h.lock.Unlock()
+
+	return ch
+}
+
+// unsubscribe removes and closes a subscriber channel.
+func (h *eventHub) unsubscribe(ch chan event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// broadcast sends an event to every connected subscriber, dropping it for
+// any subscriber that isn't keeping up rather than blocking.
+func (h *eventHub) broadcast(kind string, data interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	e := event{Kind: kind, Data: data}
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Warn("dropping event for slow SSE subscriber")
+		}
+	}
+}
+
+// diffReservations compares the old and new ResTables and broadcasts
+// "added", "removed", and "changed" events for the differences, plus a
+// "power" event when the down-node set changes.
+func diffReservations(old, cur ResTable, oldPower, newPower ResTableRow) {
+	oldByName := make(map[string]ResTableRow)
+	for _, r := range old {
+		oldByName[r.Name] = r
+	}
+
+	newByName := make(map[string]ResTableRow)
+	for _, r := range cur {
+		newByName[r.Name] = r
+	}
+
+	for name, r := range newByName {
+		if _, ok := oldByName[name]; !ok {
+			hub.broadcast("added", r)
+		} else if fmt.Sprintf("%v", oldByName[name]) != fmt.Sprintf("%v", r) {
+			hub.broadcast("changed", r)
+		}
+	}
+
+	for name, r := range oldByName {
+		if _, ok := newByName[name]; !ok {
+			hub.broadcast("removed", r)
+		}
+	}
+
+	if fmt.Sprintf("%v", oldPower.Nodes) != fmt.Sprintf("%v", newPower.Nodes) {
+		hub.broadcast("power", newPower)
+	}
+}
+
+// eventsHandler serves /events, upgrading the connection to a long-lived
+// server-sent events stream.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := hub.subscribe()
+	defer hub.unsubscribe(ch)
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			payload, err := json.Marshal(e)
+			if err != nil {
+				log.Warn("error marshaling event: %v", err)
+				continue
+			}
+
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -11,7 +11,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -38,7 +37,14 @@ The -f flag sets location of html and static folder (default = current path).
 
 The -s flag silences output.
 
-The -e flag sets the path of the igor executable to exec`
+The -e flag sets the path of the igor executable to exec
+
+The -auth flag sets the authentication backend: "pam[:service]",
+"ldap:host:port:bindDNTemplate", or "htpasswd:path" (default = pam).
+
+The -authz flag sets the path to a JSON authorization config mapping
+users/groups to allowed subcommands and nodes. If unset, any authenticated
+user may run any command against any node.`
 
 var commands = map[string]bool{
 	"del":    true,
@@ -57,6 +63,10 @@ var webP string // port
 var webF string // location of static folder
 var webS bool   // silent
 var webE string // path to igor executable
+var webAuth string  // authentication backend spec
+var webAuthz string // path to authorization config
+
+var authorizer *Authorizer
 
 var resCacheL sync.RWMutex
 var resCache ResTable
@@ -72,6 +82,8 @@ func init() {
 	flag.StringVar(&webF, "f", "", "path to static resources")
 	flag.BoolVar(&webS, "s", false, "silence output")
 	flag.StringVar(&webE, "e", "igor", "path to igor executable")
+	flag.StringVar(&webAuth, "auth", "pam", "authentication backend")
+	flag.StringVar(&webAuthz, "authz", "", "path to authorization config")
 }
 
 type AbbrevReservation struct {
@@ -166,7 +178,8 @@ func getReservations() []ResTableRow {
 	return res
 }
 
-// updates reservation data
+// updates reservation data, diffing against the previous cache and
+// broadcasting the differences to any subscribed SSE clients
 func updateReservations() {
 	log.Debug("Updating reservations")
 
@@ -213,12 +226,7 @@ func updateReservations() {
 		})
 	}
 
-	resCacheL.Lock()
-	resCache = resRows
-	resCacheL.Unlock()
-
-	powerCacheL.Lock()
-	powerCache = ResTableRow{
+	newPower := ResTableRow{
 		"",
 		"",
 		"",
@@ -227,11 +235,32 @@ func updateReservations() {
 		0,
 		rnge.RangeToInts(data.Down),
 	}
+
+	resCacheL.Lock()
+	oldResCache := resCache
+	resCache = resRows
+	resCacheL.Unlock()
+
+	powerCacheL.Lock()
+	oldPowerCache := powerCache
+	powerCache = newPower
 	powerCacheL.Unlock()
 
+	diffReservations(oldResCache, resRows, oldPowerCache, newPower)
+
 	log.Debug("Reservations updated.")
 }
 
+// updateReservationsPeriodically drives updateReservations from a single
+// background ticker, rather than having every incoming HTTP request trigger
+// its own call to "igor show".
+func updateReservationsPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		updateReservations()
+	}
+}
+
 func getDownReservation() ResTableRow {
 	powerCacheL.RLock()
 	defer powerCacheL.RUnlock()
@@ -263,24 +292,6 @@ func validCommand(args []string) error {
 	return nil
 }
 
-// Grabs the user's username from the Authorization header. This
-// header must exist in incoming requests.
-func userFromAuthHeader(r *http.Request) (string, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return "", errors.New("Invalid user.")
-	}
-
-	// strip off "Basic " and decode
-	authInfo, err := base64.StdEncoding.DecodeString(authHeader[6:])
-	if err != nil {
-		return "", errors.New("Invalid user.")
-	}
-
-	// Remove :password if it's there
-	return strings.Split(string(authInfo), ":")[0], nil
-}
-
 // handler for commands from client (sent through /run/[command])
 //              "show" is run on heartbeat, no igor command needs to be run
 func cmdHandler(w http.ResponseWriter, r *http.Request) {
@@ -299,9 +310,14 @@ func cmdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username, err := userFromAuthHeader(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	username, ok := authenticateRequest(w, r)
+	if !ok {
+		// authenticateRequest already wrote the 401
+		return
+	}
+
+	if authorizer != nil && !authorizer.Allowed(username, splitcmd[1], targetNodes(splitcmd), ownedNodes(username)) {
+		http.Error(w, "not authorized to run this command", http.StatusForbidden)
 		return
 	}
 
@@ -336,8 +352,11 @@ func cmdHandler(w http.ResponseWriter, r *http.Request) {
 		extra = specs
 
 	} else {
-		// all other commands get an updated reservations array in Response.Extra
-		updateReservations()
+		// all other commands (including "show" heartbeats) get the
+		// reservations array in Response.Extra. The cache is kept fresh by
+		// the background ticker and by the explicit updateReservations()
+		// call above for mutating commands, so heartbeats just read it
+		// rather than re-running "igor show" themselves.
 		extra = getReservations()
 	}
 
@@ -364,9 +383,6 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		log.Debug(fmt.Sprintf("%s %s %s", r.Method, r.URL, r.RemoteAddr))
 	}
 
-	// Update caches
-	updateReservations()
-
 	// serve igorweb.html with JS template variables filled in
 	//              for initial display of reservation info
 	if r.URL.Path == "/" {
@@ -399,8 +415,23 @@ func handler(w http.ResponseWriter, r *http.Request) {
 func main() {
 	flag.Parse()
 
-	// Update caches
+	a, err := newAuthenticator(webAuth)
+	if err != nil {
+		log.Fatal("invalid -auth: %v", err)
+	}
+	authenticator = a
+
+	if webAuthz != "" {
+		authorizer, err = NewAuthorizer(webAuthz)
+		if err != nil {
+			log.Fatal("invalid -authz: %v", err)
+		}
+	}
+
+	// Update caches, then keep them fresh on a ticker so that clients all
+	// see a consistent view without each request re-running "igor show"
 	updateReservations()
+	go updateReservationsPeriodically(5 * time.Second)
 
 	// handle requests for files in /static/
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(filepath.Join(webF, "static")))))
@@ -408,6 +439,8 @@ func main() {
 	http.HandleFunc("/", handler)
 	// commands
 	http.HandleFunc("/run/", cmdHandler)
+	// live reservation/power updates
+	http.HandleFunc("/events", eventsHandler)
 	// spin up server on specified port
 	log.Fatal(http.ListenAndServe("127.0.0.1:"+webP, nil).Error())
 }
\ No newline at end of file
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	log "minilog"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -30,6 +31,14 @@ type NetConfig struct {
 
 	RxRate, TxRate float64 // Most recent bandwidth measurements for Tap
 
+	// Optional per-interface tuning, set via trailing key=value fields in
+	// the netspec.
+	MTU      int             // interface MTU, 0 means unset/default
+	Queues   int             // number of tap queues, 0 means unset/default
+	QoSClass string          // tc qdisc class to apply to the tap
+	Offloads map[string]bool // ethtool -K feature -> on/off
+	RateLimit string         // tc rate limit to apply to the tap, e.g. "100mbit"
+
 	// Raw string that we used when creating this network config will be
 	// reparsed if we ever clone the VM that has this config.
 	Raw string
@@ -61,10 +70,25 @@ func NewVMConfig() VMConfig {
 //	bridge,vlan alias,mac,driver
 //
 // If there are 2 or 3 fields, just the last field for the presence of a mac
+//
+// The netspec may also have any number of trailing key=value fields, which
+// are only parsed as such when the field contains a "=", so that the
+// positional parsing above is unaffected:
+//
+//	bridge,vlan alias,mac,driver,mtu=9000,qos=class1,queues=4,offload=tso:off;gso:on,rate=100mbit
 func ParseNetConfig(spec string) (res NetConfig, err error) {
 	// example: my_bridge,100,00:00:00:00:00:00
 	f := strings.Split(spec, ",")
 
+	// split off any trailing key=value fields before doing the positional
+	// parsing below
+	kvStart := len(f)
+	for kvStart > 0 && strings.Contains(f[kvStart-1], "=") {
+		kvStart--
+	}
+	kv := f[kvStart:]
+	f = f[:kvStart]
+
 	var b, v, m, d string
 	switch len(f) {
 	case 1:
@@ -119,12 +143,60 @@ func ParseNetConfig(spec string) (res NetConfig, err error) {
 		d = DefaultKVMDriver
 	}
 
-	return NetConfig{
+	res = NetConfig{
 		Alias:  v,
 		Bridge: b,
 		MAC:    strings.ToLower(m),
 		Driver: d,
-	}, nil
+	}
+
+	if err := parseNetConfigOptions(&res, kv); err != nil {
+		return NetConfig{}, err
+	}
+
+	return res, nil
+}
+
+// parseNetConfigOptions parses the trailing key=value fields of a netspec
+// (mtu, queues, qos, offload, rate) into c.
+func parseNetConfigOptions(c *NetConfig, kv []string) error {
+	for _, field := range kv {
+		parts := strings.SplitN(field, "=", 2)
+		key, value := parts[0], parts[1]
+
+		switch key {
+		case "mtu":
+			mtu, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("malformed netspec, invalid mtu: " + value)
+			}
+			c.MTU = mtu
+		case "queues":
+			queues, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("malformed netspec, invalid queues: " + value)
+			}
+			c.Queues = queues
+		case "qos":
+			c.QoSClass = value
+		case "rate":
+			c.RateLimit = value
+		case "offload":
+			offloads := make(map[string]bool)
+			for _, pair := range strings.Split(value, ";") {
+				feature := strings.SplitN(pair, ":", 2)
+				if len(feature) != 2 || (feature[1] != "on" && feature[1] != "off") {
+					return errors.New("malformed netspec, invalid offload: " + pair)
+				}
+				offloads[feature[0]] = feature[1] == "on"
+			}
+			c.Offloads = offloads
+		default:
+			return errors.New("malformed netspec, unknown option: " + key)
+		}
+	}
+
+	return nil
 }
 
 // String representation of NetConfig, should be able to parse back into a
@@ -155,6 +227,36 @@ func (c NetConfig) String() string {
 		parts = append(parts, prep(c.Driver))
 	}
 
+	if c.MTU != 0 {
+		parts = append(parts, fmt.Sprintf("mtu=%v", c.MTU))
+	}
+	if c.Queues != 0 {
+		parts = append(parts, fmt.Sprintf("queues=%v", c.Queues))
+	}
+	if c.QoSClass != "" {
+		parts = append(parts, fmt.Sprintf("qos=%v", c.QoSClass))
+	}
+	if len(c.Offloads) > 0 {
+		features := []string{}
+		for feature := range c.Offloads {
+			features = append(features, feature)
+		}
+		sort.Strings(features)
+
+		pairs := []string{}
+		for _, feature := range features {
+			state := "off"
+			if c.Offloads[feature] {
+				state = "on"
+			}
+			pairs = append(pairs, fmt.Sprintf("%v:%v", feature, state))
+		}
+		parts = append(parts, fmt.Sprintf("offload=%v", strings.Join(pairs, ";")))
+	}
+	if c.RateLimit != "" {
+		parts = append(parts, fmt.Sprintf("rate=%v", c.RateLimit))
+	}
+
 	return strings.Join(parts, ",")
 }
 
@@ -0,0 +1,87 @@
+// Copyright (2012) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package main
+
+import "testing"
+
+func TestParseNetConfigPositional(t *testing.T) {
+	cases := []struct {
+		spec   string
+		alias  string
+		bridge string
+	}{
+		{"100", "100", ""},
+		{"br0,100", "100", "br0"},
+	}
+
+	for _, c := range cases {
+		res, err := ParseNetConfig(c.spec)
+		if err != nil {
+			t.Fatalf("ParseNetConfig(%q): %v", c.spec, err)
+		}
+		if res.Alias != c.alias {
+			t.Errorf("ParseNetConfig(%q).Alias = %v, want %v", c.spec, res.Alias, c.alias)
+		}
+		if c.bridge != "" && res.Bridge != c.bridge {
+			t.Errorf("ParseNetConfig(%q).Bridge = %v, want %v", c.spec, res.Bridge, c.bridge)
+		}
+	}
+}
+
+func TestParseNetConfigOptions(t *testing.T) {
+	res, err := ParseNetConfig("br0,100,mtu=9000,qos=class1,queues=4,offload=tso:off;gso:on,rate=100mbit")
+	if err != nil {
+		t.Fatalf("ParseNetConfig: %v", err)
+	}
+
+	if res.MTU != 9000 {
+		t.Errorf("MTU = %v, want 9000", res.MTU)
+	}
+	if res.Queues != 4 {
+		t.Errorf("Queues = %v, want 4", res.Queues)
+	}
+	if res.QoSClass != "class1" {
+		t.Errorf("QoSClass = %v, want class1", res.QoSClass)
+	}
+	if res.RateLimit != "100mbit" {
+		t.Errorf("RateLimit = %v, want 100mbit", res.RateLimit)
+	}
+	if res.Offloads["tso"] != false || res.Offloads["gso"] != true {
+		t.Errorf("Offloads = %v, want tso:off gso:on", res.Offloads)
+	}
+}
+
+func TestParseNetConfigOptionsInvalid(t *testing.T) {
+	cases := []string{
+		"br0,100,mtu=notanumber",
+		"br0,100,queues=notanumber",
+		"br0,100,offload=tso:sideways",
+		"br0,100,bogus=1",
+	}
+
+	for _, spec := range cases {
+		if _, err := ParseNetConfig(spec); err == nil {
+			t.Errorf("ParseNetConfig(%q): expected error, got nil", spec)
+		}
+	}
+}
+
+func TestNetConfigStringRoundTrip(t *testing.T) {
+	spec := "br0,100,mtu=9000,qos=class1,queues=4,offload=gso:on;tso:off,rate=100mbit"
+
+	res, err := ParseNetConfig(spec)
+	if err != nil {
+		t.Fatalf("ParseNetConfig: %v", err)
+	}
+
+	again, err := ParseNetConfig(res.String())
+	if err != nil {
+		t.Fatalf("ParseNetConfig(%q): %v", res.String(), err)
+	}
+
+	if again.MTU != res.MTU || again.QoSClass != res.QoSClass || again.RateLimit != res.RateLimit {
+		t.Errorf("round trip mismatch: %+v vs %+v", res, again)
+	}
+}
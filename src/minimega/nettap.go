@@ -0,0 +1,135 @@
+// Copyright (2012) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package main
+
+import (
+	"fmt"
+	log "minilog"
+	"os/exec"
+	"sort"
+)
+
+// applyNetConfigTuning applies the optional MTU, queue count, QoS/rate-limit,
+// and offload settings of a NetConfig to an already-created tap. It is meant
+// to be called once a VM's tap has been created, after the tap has been
+// attached to its bridge.
+//
+// NOTE: the tap-creation code this is meant to be called from isn't part of
+// this tree, so nothing invokes applyNetConfigTuning yet; it's dead code
+// until that wiring lands.
+func applyNetConfigTuning(tap string, c NetConfig) error {
+	if c.MTU != 0 {
+		if err := tapSetMTU(tap, c.MTU); err != nil {
+			return fmt.Errorf("set mtu on %v: %v", tap, err)
+		}
+	}
+
+	if c.Queues != 0 {
+		if err := tapSetQueues(tap, c.Queues); err != nil {
+			return fmt.Errorf("set queues on %v: %v", tap, err)
+		}
+	}
+
+	if c.RateLimit != "" || c.QoSClass != "" {
+		if err := tapSetQoS(tap, c.QoSClass, c.RateLimit); err != nil {
+			return fmt.Errorf("set qos on %v: %v", tap, err)
+		}
+	}
+
+	if len(c.Offloads) > 0 {
+		if err := tapSetOffloads(tap, c.Offloads); err != nil {
+			return fmt.Errorf("set offloads on %v: %v", tap, err)
+		}
+	}
+
+	return nil
+}
+
+// tapSetMTU sets a tap's MTU via `ip link set`.
+func tapSetMTU(tap string, mtu int) error {
+	log.Debug("setting mtu %v on tap %v", mtu, tap)
+
+	out, err := exec.Command("ip", "link", "set", "dev", tap, "mtu", fmt.Sprintf("%v", mtu)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, string(out))
+	}
+
+	return nil
+}
+
+// tapSetQueues sets the number of combined rx/tx queues exposed by a
+// multiqueue tap via `ethtool -L`.
+func tapSetQueues(tap string, queues int) error {
+	log.Debug("setting queues %v on tap %v", queues, tap)
+
+	out, err := exec.Command("ethtool", "-L", tap, "combined", fmt.Sprintf("%v", queues)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, string(out))
+	}
+
+	return nil
+}
+
+// tapSetQoS programs a tc qdisc on a tap for the given QoS class and/or rate
+// limit. If class is set, an htb root qdisc with handle 1: is installed,
+// with rate (if set) applied to its default class 1:1; the class name itself
+// is not passed to tc. Otherwise a tbf qdisc limits the tap to rate. Any
+// existing root qdisc is deleted first, so repeated calls replace rather
+// than stack qdiscs.
+func tapSetQoS(tap, class, rate string) error {
+	log.Debug("setting qos class=%v rate=%v on tap %v", class, rate, tap)
+
+	// clear any qdisc we previously installed; ignore errors since there
+	// may not be one yet
+	exec.Command("tc", "qdisc", "del", "dev", tap, "root").Run()
+
+	args := []string{"qdisc", "add", "dev", tap, "root"}
+	if class != "" {
+		args = append(args, "handle", "1:", "htb", "default", "1")
+	} else {
+		args = append(args, "tbf", "rate", rate, "burst", "32kbit", "latency", "400ms")
+	}
+
+	out, err := exec.Command("tc", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, string(out))
+	}
+
+	if class != "" && rate != "" {
+		out, err := exec.Command("tc", "class", "add", "dev", tap, "parent", "1:", "classid", "1:1", "htb", "rate", rate).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v: %v", err, string(out))
+		}
+	}
+
+	return nil
+}
+
+// tapSetOffloads toggles NIC offload features on a tap via `ethtool -K`.
+func tapSetOffloads(tap string, offloads map[string]bool) error {
+	features := []string{}
+	for f := range offloads {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+
+	args := []string{"-K", tap}
+	for _, f := range features {
+		state := "off"
+		if offloads[f] {
+			state = "on"
+		}
+		args = append(args, f, state)
+	}
+
+	log.Debug("setting offloads %v on tap %v", args[2:], tap)
+
+	out, err := exec.Command("ethtool", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, string(out))
+	}
+
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 	"miniplumber"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -40,7 +41,15 @@ create a simple tree rooted at A with leaves B and C, simply specify multiple
 pipelines:
 
 	plumb a b
-	plumb a c`,
+	plumb a c
+
+The source of a pipeline may also be a topic pattern, using "+" to match
+exactly one "/"-delimited segment and "#" to match the rest of the name.
+Every pipe whose name matches the pattern, whether it already exists or is
+created later, is wired into the rest of the pipeline:
+
+	plumb "vms/+/stdout" collector
+	plumb "sensors/#" logger`,
 		Patterns: []string{
 			"plumb <src> <dst>...",
 		},
@@ -80,11 +89,35 @@ example, to send a unique floating-point value on a normal distribution with a
 written mean to all readers:
 
 	pipe foo via normal -stddev 5.0
-	pipe foo 1.5`,
+	pipe foo 1.5
+
+The pipe name given to via may also be a topic pattern (see "plumb" above),
+in which case the via is applied to every existing or future pipe whose
+name matches:
+
+	pipe sensors/# via jq -c .temperature
+
+Pipes can be logged to one or more sinks. The filesystem sink rotates log
+files based on a max-size (in MB), max-age (in days), and max-backups policy.
+The console sink writes to minimega's stdout or stderr. Multiple sinks may be
+attached to the same pipe at once:
+
+	pipe foo log filesystem /var/log/mm/foo.log max-size=100 max-age=7 max-backups=5
+	pipe foo log console stderr
+
+By default, data written to a pipe is split into messages on newlines. A
+pipe's framing can instead be set to "json", delivering one complete
+top-level JSON value per message, or "length", delivering messages prefixed
+with a 4-byte big-endian length. Partial frames are buffered until they are
+completed by a subsequent write:
+
+	pipe foo framing json`,
 		Patterns: []string{
 			"pipe",
 			"pipe <pipe> <mode,> <all,round-robin,random>",
-			"pipe <pipe> <log,> <true,false>",
+			"pipe <pipe> <log,> <filesystem,> <path> [options]...",
+			"pipe <pipe> <log,> <console,> <stdout,stderr>",
+			"pipe <pipe> <framing,> <newline,json,length>",
 		},
 		Call: wrapBroadcastCLI(cliPipeBroadcast),
 	},
@@ -181,11 +214,22 @@ func cliPipeBroadcast(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 
 		return nil
 	} else if c.BoolArgs["log"] {
-		if c.BoolArgs["true"] {
-			plumber.Log(pipe, true)
+		sink, err := parseLogSink(c)
+		if err != nil {
+			return err
+		}
+
+		plumber.Log(pipe, sink)
+	} else if c.BoolArgs["framing"] {
+		var framing miniplumber.Framing
+		if c.BoolArgs["json"] {
+			framing = miniplumber.FramingJSON
+		} else if c.BoolArgs["length"] {
+			framing = miniplumber.FramingLength
 		} else {
-			plumber.Log(pipe, false)
+			framing = miniplumber.FramingNewline
 		}
+		plumber.Framing(pipe, framing)
 	} else {
 		// get info on all named pipes
 		resp.Header = []string{"name", "mode", "readers", "writers", "via", "last message"}
@@ -206,6 +250,47 @@ func cliPipeBroadcast(ns *Namespace, c *minicli.Command, resp *minicli.Response)
 	return nil
 }
 
+// parseLogSink builds a miniplumber.Sink from the parsed `pipe <pipe> log
+// ...` command, dispatching on the filesystem/console sink type.
+func parseLogSink(c *minicli.Command) (miniplumber.Sink, error) {
+	if c.BoolArgs["console"] {
+		stream := "stdout"
+		if c.BoolArgs["stderr"] {
+			stream = "stderr"
+		}
+
+		return miniplumber.NewConsoleSink(stream)
+	}
+
+	// filesystem sink
+	var maxSize, maxAge, maxBackups int
+
+	for _, opt := range c.ListArgs["options"] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed log option: %v", opt)
+		}
+
+		v, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed log option: %v", opt)
+		}
+
+		switch kv[0] {
+		case "max-size":
+			maxSize = v
+		case "max-age":
+			maxAge = v
+		case "max-backups":
+			maxBackups = v
+		default:
+			return nil, fmt.Errorf("unknown log option: %v", kv[0])
+		}
+	}
+
+	return miniplumber.NewFileSink(c.StringArgs["path"], maxSize, maxAge, maxBackups)
+}
+
 func cliPipeLocal(ns *Namespace, c *minicli.Command, resp *minicli.Response) error {
 	pipe := c.StringArgs["pipe"]
 
@@ -237,7 +322,7 @@ func cliPipeClear(ns *Namespace, c *minicli.Command, resp *minicli.Response) err
 		if !ok {
 			return fmt.Errorf("no such pipe: %v", pipe)
 		}
-		plumber.Log(pipe, false)
+		plumber.ClearLog(pipe)
 	} else if c.BoolArgs["via"] {
 		if !ok {
 			return fmt.Errorf("no such pipe: %v", pipe)
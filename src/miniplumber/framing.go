@@ -0,0 +1,95 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Framing controls how a pipe splits a stream of writes into discrete
+// messages for its readers and sinks.
+type Framing int
+
+const (
+	// FramingNewline splits on '\n', the historical behavior of pipes.
+	FramingNewline Framing = iota
+	// FramingJSON delivers one complete top-level JSON value per frame,
+	// so producers/consumers can exchange multi-line JSON objects.
+	FramingJSON
+	// FramingLength delivers frames prefixed with a 4-byte big-endian
+	// length, for exchanging length-prefixed binary blobs.
+	FramingLength
+)
+
+// extractFrames consumes as many complete frames as are available from buf
+// according to mode, returning the frames found and the unconsumed
+// remainder of buf.
+func extractFrames(mode Framing, buf []byte) (frames [][]byte, remainder []byte) {
+	switch mode {
+	case FramingJSON:
+		return extractJSONFrames(buf)
+	case FramingLength:
+		return extractLengthFrames(buf)
+	default:
+		return extractNewlineFrames(buf)
+	}
+}
+
+func extractNewlineFrames(buf []byte) ([][]byte, []byte) {
+	frames := [][]byte{}
+
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		frames = append(frames, buf[:i])
+		buf = buf[i+1:]
+	}
+
+	return frames, buf
+}
+
+func extractJSONFrames(buf []byte) ([][]byte, []byte) {
+	frames := [][]byte{}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	offset := int64(0)
+
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+
+		frames = append(frames, []byte(raw))
+		offset = dec.InputOffset()
+	}
+
+	return frames, buf[offset:]
+}
+
+func extractLengthFrames(buf []byte) ([][]byte, []byte) {
+	frames := [][]byte{}
+
+	for {
+		if len(buf) < 4 {
+			break
+		}
+
+		n := binary.BigEndian.Uint32(buf[:4])
+		if uint32(len(buf)-4) < n {
+			break
+		}
+
+		frames = append(frames, buf[4:4+n])
+		buf = buf[4+n:]
+	}
+
+	return frames, buf
+}
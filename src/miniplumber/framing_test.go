@@ -0,0 +1,53 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestExtractNewlineFrames(t *testing.T) {
+	frames, remainder := extractFrames(FramingNewline, []byte("foo\nbar\nbaz"))
+
+	if len(frames) != 2 || string(frames[0]) != "foo" || string(frames[1]) != "bar" {
+		t.Fatalf("unexpected frames: %q", frames)
+	}
+	if string(remainder) != "baz" {
+		t.Fatalf("unexpected remainder: %q", remainder)
+	}
+}
+
+func TestExtractJSONFrames(t *testing.T) {
+	frames, remainder := extractFrames(FramingJSON, []byte(`{"a":1}{"b":2}{"c"`))
+
+	if len(frames) != 2 || string(frames[0]) != `{"a":1}` || string(frames[1]) != `{"b":2}` {
+		t.Fatalf("unexpected frames: %q", frames)
+	}
+	if string(remainder) != `{"c"` {
+		t.Fatalf("unexpected remainder: %q", remainder)
+	}
+}
+
+func TestExtractLengthFrames(t *testing.T) {
+	buf := []byte{}
+	for _, s := range []string{"hello", "world"} {
+		prefix := make([]byte, 4)
+		binary.BigEndian.PutUint32(prefix, uint32(len(s)))
+		buf = append(buf, prefix...)
+		buf = append(buf, []byte(s)...)
+	}
+	// partial trailing frame
+	buf = append(buf, 0, 0, 0, 10, 'h', 'i')
+
+	frames, remainder := extractFrames(FramingLength, buf)
+
+	if len(frames) != 2 || string(frames[0]) != "hello" || string(frames[1]) != "world" {
+		t.Fatalf("unexpected frames: %q", frames)
+	}
+	if len(remainder) != 6 {
+		t.Fatalf("unexpected remainder length: %v", len(remainder))
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+// Package miniplumber implements a distributed named pipe system, allowing
+// external programs, VMs, and minimega itself to be composed into pipelines
+// similar to UNIX pipes.
+package miniplumber
+
+import (
+	"fmt"
+	"meshage"
+	log "minilog"
+	"strings"
+	"sync"
+)
+
+const (
+	MODE_ALL = iota
+	MODE_RR
+	MODE_RND
+)
+
+// Plumber tracks all of the named pipes and pipelines known to a single
+// minimega instance.
+type Plumber struct {
+	node *meshage.Node
+
+	lock             sync.Mutex
+	pipes            map[string]*Pipe
+	pipelines        map[string][]string
+	subscriptions    []subscription
+	viaSubscriptions []viaSubscription
+}
+
+// New creates a new Plumber, bound to the given meshage node for
+// distributing pipe traffic across a cluster.
+func New(node *meshage.Node) *Plumber {
+	return &Plumber{
+		node:      node,
+		pipes:     make(map[string]*Pipe),
+		pipelines: make(map[string][]string),
+	}
+}
+
+// getPipe returns the named pipe, creating it if it does not already exist.
+// Newly created pipes are automatically wired into any topic subscriptions
+// registered with Plumb whose pattern matches the new name.
+func (p *Plumber) getPipe(name string) *Pipe {
+	p.lock.Lock()
+	if pipe, ok := p.pipes[name]; ok {
+		p.lock.Unlock()
+		return pipe
+	}
+
+	pipe := newPipe(name)
+	p.pipes[name] = pipe
+	p.lock.Unlock()
+
+	for _, s := range p.matchSubscriptions(name) {
+		p.registerPipeline(append([]string{name}, s.dst...))
+	}
+	for _, command := range p.matchViaSubscriptions(name) {
+		pipe.setVia(command)
+	}
+
+	return pipe
+}
+
+// registerPipeline records a pipeline connecting the given names, in order.
+func (p *Plumber) registerPipeline(names []string) {
+	key := strings.Join(names, " -> ")
+
+	p.lock.Lock()
+	p.pipelines[key] = names
+	p.lock.Unlock()
+
+	log.Debug("plumbed pipeline: %v", key)
+}
+
+// Plumb records a pipeline connecting the given names, in order. It does
+// not look names up in $PATH, launch external commands, or move data
+// between the pipes; it only registers the pipeline.
+//
+// If the first name is a topic pattern (contains a "+" or "#" wildcard
+// segment), Plumb instead registers a subscription: every existing or
+// future pipe whose name matches the pattern is wired into the remaining
+// names, as if "plumb <matching pipe> <names[1:]...>" had been called for
+// it directly. Writers are unaffected by subscriptions.
+func (p *Plumber) Plumb(names ...string) error {
+	if len(names) < 2 {
+		return fmt.Errorf("plumb requires at least two endpoints")
+	}
+
+	if isPattern(names[0]) {
+		for _, match := range p.subscribe(names[0], names[1:]) {
+			p.registerPipeline(append([]string{match}, names[1:]...))
+		}
+
+		return nil
+	}
+
+	p.registerPipeline(names)
+
+	return nil
+}
+
+// Pipelines returns the names of all known pipelines.
+func (p *Plumber) Pipelines() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	res := []string{}
+	for k := range p.pipelines {
+		res = append(res, k)
+	}
+	return res
+}
+
+// PipelineDelete removes the named pipelines.
+func (p *Plumber) PipelineDelete(names ...string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, n := range names {
+		delete(p.pipelines, n)
+	}
+
+	return nil
+}
+
+// PipelineDeleteAll removes all known pipelines.
+func (p *Plumber) PipelineDeleteAll() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.pipelines = make(map[string][]string)
+
+	return nil
+}
+
+// Pipes returns all known named pipes.
+func (p *Plumber) Pipes() []*Pipe {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	res := []*Pipe{}
+	for _, pipe := range p.pipes {
+		res = append(res, pipe)
+	}
+	return res
+}
+
+// PipeDelete removes the named pipe.
+func (p *Plumber) PipeDelete(name string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pipe, ok := p.pipes[name]
+	if !ok {
+		return fmt.Errorf("no such pipe: %v", name)
+	}
+	pipe.close()
+	delete(p.pipes, name)
+
+	return nil
+}
+
+// PipeDeleteAll removes all known named pipes.
+func (p *Plumber) PipeDeleteAll() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, pipe := range p.pipes {
+		pipe.close()
+	}
+	p.pipes = make(map[string]*Pipe)
+
+	return nil
+}
+
+// Mode sets the delivery mode for the named pipe.
+func (p *Plumber) Mode(name string, mode int) {
+	p.getPipe(name).setMode(mode)
+}
+
+// Via sets, or clears if command is empty, the via command for the named
+// pipe. If name is a topic pattern (see Plumb), the via is instead applied
+// to every existing or future pipe whose name matches the pattern, e.g.
+// "pipe sensors/# via jq ...".
+func (p *Plumber) Via(name string, command []string) {
+	if isPattern(name) {
+		for _, match := range p.subscribeVia(name, command) {
+			p.getPipe(match).setVia(command)
+		}
+		return
+	}
+
+	p.getPipe(name).setVia(command)
+}
+
+// Write writes data to the named pipe, delivering each complete frame to the
+// pipe's sinks. Frames are not delivered to readers.
+func (p *Plumber) Write(name, data string) error {
+	return p.getPipe(name).write([]byte(data))
+}
+
+// Framing sets the framing mode for the named pipe.
+func (p *Plumber) Framing(name string, framing Framing) {
+	p.getPipe(name).setFraming(framing)
+}
@@ -0,0 +1,153 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import (
+	"strings"
+	"sync"
+)
+
+// Pipe is a single named pipe. It records a delivery mode and reader and
+// writer counts, but has no reader connections: written messages go only to
+// the zero or more Sinks attached to it, which receive a copy of every
+// message written to the pipe for logging purposes.
+type Pipe struct {
+	name string
+
+	lock    sync.Mutex
+	mode    int
+	via     []string
+	readers int
+	writers int
+	last    string
+	sinks   []Sink
+
+	framing Framing
+	buf     []byte
+}
+
+func newPipe(name string) *Pipe {
+	return &Pipe{
+		name:    name,
+		mode:    MODE_ALL,
+		framing: FramingNewline,
+	}
+}
+
+// setFraming sets the pipe's framing mode. Any bytes already buffered
+// waiting on a partial frame are kept and reparsed under the new mode.
+func (p *Pipe) setFraming(framing Framing) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.framing = framing
+}
+
+func (p *Pipe) Name() string {
+	return p.name
+}
+
+func (p *Pipe) Mode() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch p.mode {
+	case MODE_RR:
+		return "round-robin"
+	case MODE_RND:
+		return "random"
+	default:
+		return "all"
+	}
+}
+
+func (p *Pipe) setMode(mode int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.mode = mode
+}
+
+func (p *Pipe) NumReaders() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.readers
+}
+
+func (p *Pipe) NumWriters() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.writers
+}
+
+func (p *Pipe) GetVia() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return strings.Join(p.via, " ")
+}
+
+func (p *Pipe) setVia(command []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.via = command
+}
+
+func (p *Pipe) Last() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.last
+}
+
+// addSink attaches a new sink to the pipe. Multiple sinks may be attached
+// simultaneously, so that a single pipe can be logged to, for example, both
+// a rotating file and the console at once.
+func (p *Pipe) addSink(sink Sink) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.sinks = append(p.sinks, sink)
+}
+
+// clearSinks detaches and closes every sink attached to the pipe.
+func (p *Pipe) clearSinks() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, sink := range p.sinks {
+		sink.Close()
+	}
+	p.sinks = nil
+}
+
+// write buffers the incoming bytes and delivers only the complete frames
+// they produce, per the pipe's framing mode, to the pipe's sinks.
+func (p *Pipe) write(data []byte) error {
+	p.lock.Lock()
+	p.buf = append(p.buf, data...)
+	frames, remainder := extractFrames(p.framing, p.buf)
+	p.buf = remainder
+	if len(frames) > 0 {
+		p.last = string(frames[len(frames)-1])
+	}
+	sinks := p.sinks
+	p.lock.Unlock()
+
+	for _, frame := range frames {
+		for _, sink := range sinks {
+			sink.Write(frame)
+		}
+	}
+
+	return nil
+}
+
+func (p *Pipe) close() {
+	p.clearSinks()
+}
@@ -0,0 +1,206 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sink is a destination for data written to a pipe. Sinks are used to
+// implement `pipe <pipe> log ...`, allowing pipe traffic to be durably
+// logged without the user having to plumb through an external tee or
+// logrotate.
+type Sink interface {
+	Write([]byte) error
+	Close() error
+}
+
+// ConsoleSink writes pipe data to stdout or stderr. Writes are
+// synchronized so that multiple pipes (or multiple writers to the same
+// pipe) logging to the console concurrently don't interleave.
+type ConsoleSink struct {
+	lock sync.Mutex
+	f    *os.File
+}
+
+// NewConsoleSink creates a Sink that writes to the given stream, which must
+// be "stdout" or "stderr".
+func NewConsoleSink(stream string) (Sink, error) {
+	switch stream {
+	case "stdout":
+		return &ConsoleSink{f: os.Stdout}, nil
+	case "stderr":
+		return &ConsoleSink{f: os.Stderr}, nil
+	default:
+		return nil, fmt.Errorf("invalid console stream: %v", stream)
+	}
+}
+
+func (c *ConsoleSink) Write(data []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, err := c.f.Write(data)
+	return err
+}
+
+func (c *ConsoleSink) Close() error {
+	// never close stdout/stderr out from under the rest of the process
+	return nil
+}
+
+// FileSink is a rotating, size- and age-bounded filesystem log. When the
+// current log file reaches maxSizeMB or maxAgeDays, it is renamed with a
+// timestamp suffix and a new file is started. Backups beyond maxBackups are
+// pruned, oldest first. Writes (and the rotation they can trigger) are
+// synchronized, since a pipe may hand the same sink concurrent writes.
+type FileSink struct {
+	path       string
+	maxSizeMB  int
+	maxAgeDays int
+	maxBackups int
+
+	lock     sync.Mutex
+	f        *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileSink creates a rotating filesystem Sink at path. A maxSizeMB,
+// maxAgeDays, or maxBackups of 0 disables that rotation policy.
+func NewFileSink(path string, maxSizeMB, maxAgeDays, maxBackups int) (Sink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSizeMB:  maxSizeMB,
+		maxAgeDays: maxAgeDays,
+		maxBackups: maxBackups,
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.f = f
+	s.size = info.Size()
+	s.openedAt = time.Now()
+
+	return nil
+}
+
+func (s *FileSink) Write(data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.needsRotation() {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(data)
+	s.size += int64(n)
+
+	return err
+}
+
+func (s *FileSink) needsRotation() bool {
+	if s.maxSizeMB > 0 && s.size >= int64(s.maxSizeMB)*1024*1024 {
+		return true
+	}
+	if s.maxAgeDays > 0 && time.Since(s.openedAt) >= time.Duration(s.maxAgeDays)*24*time.Hour {
+		return true
+	}
+
+	return false
+}
+
+// rotate renames the current log file with a timestamp suffix, opens a
+// fresh file in its place, and prunes old backups beyond maxBackups.
+func (s *FileSink) rotate() error {
+	if s.f != nil {
+		s.f.Close()
+	}
+
+	// include nanoseconds so that consecutive rotations within the same
+	// second, which a busy pipe can easily trigger, don't collide and
+	// silently clobber a prior backup
+	backup := fmt.Sprintf("%v.%v", s.path, time.Now().Format("20060102-150405.000000000"))
+	if err := os.Rename(s.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	return s.prune()
+}
+
+// prune removes old backups of the log file beyond maxBackups, oldest
+// first.
+func (s *FileSink) prune() error {
+	if s.maxBackups <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return err
+	}
+
+	if len(matches) <= s.maxBackups {
+		return nil
+	}
+
+	sort.Strings(matches)
+
+	for _, old := range matches[:len(matches)-s.maxBackups] {
+		os.Remove(old)
+	}
+
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+
+	return s.f.Close()
+}
+
+// Log attaches a sink to the named pipe. Multiple sinks may be attached to
+// the same pipe simultaneously.
+func (p *Plumber) Log(name string, sink Sink) {
+	p.getPipe(name).addSink(sink)
+}
+
+// ClearLog detaches and closes all sinks attached to the named pipe.
+func (p *Plumber) ClearLog(name string) {
+	p.getPipe(name).clearSinks()
+}
@@ -0,0 +1,85 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSinkRotatesOnSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "miniplumber-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+
+	sink, err := NewFileSink(path, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*FileSink)
+	s.maxSizeMB = 0
+	// force rotation after any write by pretending the file is already huge
+	s.size = 1 << 30
+	s.maxSizeMB = 1
+
+	if err := s.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 backup after rotation, got %v: %v", len(matches), matches)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected fresh log file to exist: %v", err)
+	}
+}
+
+func TestFileSinkPrunesOldBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "miniplumber-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+
+	sink, err := NewFileSink(path, 1, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*FileSink)
+
+	for i := 0; i < 5; i++ {
+		s.size = int64(s.maxSizeMB) * 1024 * 1024
+		if err := s.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != s.maxBackups {
+		t.Fatalf("expected %v backups after pruning, got %v: %v", s.maxBackups, len(matches), matches)
+	}
+}
+
+func TestConsoleSinkInvalidStream(t *testing.T) {
+	if _, err := NewConsoleSink("bogus"); err == nil {
+		t.Fatal("expected error for invalid console stream")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import "strings"
+
+// subscription is a pattern registered via Plumb, paired with the
+// downstream pipeline endpoints it should be wired into whenever a pipe
+// whose name matches the pattern is created.
+type subscription struct {
+	pattern string
+	dst     []string
+}
+
+// viaSubscription is a pattern registered via Via, paired with the command
+// that should be set as the via of any pipe whose name matches the
+// pattern.
+type viaSubscription struct {
+	pattern string
+	command []string
+}
+
+// isPattern reports whether name contains MQTT-style wildcard segments, and
+// should therefore be treated as a topic subscription rather than a literal
+// pipe name.
+func isPattern(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if seg == "+" || seg == "#" {
+			return true
+		}
+	}
+	return false
+}
+
+// topicMatch reports whether name matches pattern, where pattern segments
+// may be "+" (match exactly one segment) or "#" (match the rest of the
+// name, at least one segment, and must be the final segment).
+func topicMatch(pattern, name string) bool {
+	pSegs := strings.Split(pattern, "/")
+	nSegs := strings.Split(name, "/")
+
+	for i, p := range pSegs {
+		if p == "#" {
+			return i < len(nSegs)
+		}
+
+		if i >= len(nSegs) {
+			return false
+		}
+
+		if p != "+" && p != nSegs[i] {
+			return false
+		}
+	}
+
+	return len(pSegs) == len(nSegs)
+}
+
+// subscribe registers a pattern subscription and returns the names of the
+// existing pipes that match it; wiring those pipes in is left to the caller.
+func (p *Plumber) subscribe(pattern string, dst []string) []string {
+	p.lock.Lock()
+	p.subscriptions = append(p.subscriptions, subscription{pattern: pattern, dst: dst})
+	matched := []string{}
+	for name := range p.pipes {
+		if topicMatch(pattern, name) {
+			matched = append(matched, name)
+		}
+	}
+	p.lock.Unlock()
+
+	return matched
+}
+
+// matchSubscriptions returns the subscriptions whose pattern matches name,
+// called whenever a new pipe is created so that it can be wired into any
+// topic subscriptions already registered.
+func (p *Plumber) matchSubscriptions(name string) []subscription {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	matched := []subscription{}
+	for _, s := range p.subscriptions {
+		if topicMatch(s.pattern, name) {
+			matched = append(matched, s)
+		}
+	}
+
+	return matched
+}
+
+// subscribeVia registers a via pattern subscription and returns the names
+// of the existing pipes that match it; setting their via is left to the
+// caller.
+func (p *Plumber) subscribeVia(pattern string, command []string) []string {
+	p.lock.Lock()
+	p.viaSubscriptions = append(p.viaSubscriptions, viaSubscription{pattern: pattern, command: command})
+	matched := []string{}
+	for name := range p.pipes {
+		if topicMatch(pattern, name) {
+			matched = append(matched, name)
+		}
+	}
+	p.lock.Unlock()
+
+	return matched
+}
+
+// matchViaSubscriptions returns the via commands of every registered via
+// pattern that matches name, in registration order.
+func (p *Plumber) matchViaSubscriptions(name string) [][]string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	matched := [][]string{}
+	for _, s := range p.viaSubscriptions {
+		if topicMatch(s.pattern, name) {
+			matched = append(matched, s.command)
+		}
+	}
+
+	return matched
+}
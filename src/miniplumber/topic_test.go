@@ -0,0 +1,48 @@
+// Copyright (2017) Sandia Corporation.
+// Under the terms of Contract DE-AC04-94AL85000 with Sandia Corporation,
+// the U.S. Government retains certain rights in this software.
+
+package miniplumber
+
+import "testing"
+
+func TestTopicMatch(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"vms/+/stdout", "vms/vm0/stdout", true},
+		{"vms/+/stdout", "vms/vm0/vm1/stdout", false},
+		{"vms/+/stdout", "vms/vm0/stderr", false},
+		{"sensors/#", "sensors/temp/room1", true},
+		{"sensors/#", "sensors", false},
+		{"sensors/#", "sensors/temp", true},
+		{"foo", "foo", true},
+		{"foo", "foobar", false},
+		{"foo/bar", "foo/bar/baz", false},
+	}
+
+	for _, c := range cases {
+		if got := topicMatch(c.pattern, c.name); got != c.want {
+			t.Errorf("topicMatch(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"vms/+/stdout", true},
+		{"sensors/#", true},
+		{"vms/vm0/stdout", false},
+		{"foo", false},
+	}
+
+	for _, c := range cases {
+		if got := isPattern(c.name); got != c.want {
+			t.Errorf("isPattern(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}